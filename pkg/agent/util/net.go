@@ -17,16 +17,43 @@ package util
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
 	interfaceNameLength   = 15
 	interfacePrefixLength = 8
 	interfaceKeyLen       = interfaceNameLength - (interfacePrefixLength + 1)
+	// maxNameAllocProbes bounds the number of candidate names (the unsalted one plus
+	// its salted retries) Allocate tries before giving up on a Pod whose generated
+	// names keep colliding.
+	maxNameAllocProbes = 256
+	// interfaceRegistryFile is the name of the on-disk registry file, stored under the
+	// agent's state directory.
+	interfaceRegistryFile = "interfaces.json"
 )
 
+var interfaceNameCollisions = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "antrea_agent_interface_name_collisions_total",
+	Help: "Number of times a generated container interface name collided with an existing allocation and had to be retried with a new salt.",
+})
+
+// RegisterMetrics registers the interface name allocator's Prometheus metrics with
+// registerer. It is called once from agent startup, alongside the rest of the agent's
+// collectors, rather than from an init() so registration happens at a well-defined point
+// instead of as a side effect of importing this package.
+func RegisterMetrics(registerer prometheus.Registerer) {
+	registerer.MustRegister(interfaceNameCollisions)
+}
+
 func generateInterfaceName(id string, prefix string) string {
 	hash := sha1.New()
 	io.WriteString(hash, id)
@@ -41,7 +68,10 @@ func generateInterfaceName(id string, prefix string) string {
 // Pod's Namespace and name. The output should be deterministic (so that
 // multiple calls to GenerateContainerInterfaceName with the same parameters
 // return the same value). The output has the length of interfaceNameLength(15).
-// The probability of collision should be neglectable.
+//
+// Deprecated: the truncated hash used here can collide on dense nodes with high
+// Pod churn; use NameAllocator.Allocate instead, which detects and resolves
+// collisions and persists the allocation across agent restarts.
 func GenerateContainerInterfaceName(podName string, podNamespace string) string {
 	id := fmt.Sprintf("pod/%s/%s", podNamespace, podName)
 	return generateInterfaceName(id, podName)
@@ -53,3 +83,130 @@ func GenerateTunnelInterfaceName(nodeName string) string {
 	id := fmt.Sprintf("node/%s", nodeName)
 	return generateInterfaceName(id, nodeName)
 }
+
+// interfaceOwner records which Pod a generated interface name was handed out to, so the
+// registry can both detect collisions and survive agent restarts.
+type interfaceOwner struct {
+	PodNamespace string `json:"podNamespace"`
+	PodName      string `json:"podName"`
+	ContainerID  string `json:"containerID"`
+}
+
+// NameAllocator allocates collision-safe container interface names backed by a
+// JSON registry under the agent's state directory, keyed by the generated name. On a
+// collision between two different Pods it probes successive salts until it finds a
+// free name within the 15-character Linux ifname limit.
+type NameAllocator struct {
+	mutex     sync.Mutex
+	file      string
+	allocated map[string]interfaceOwner
+}
+
+// NewNameAllocator creates a NameAllocator whose registry lives under stateDir,
+// reloading any existing registry from disk so that names remain stable across
+// agent restarts.
+func NewNameAllocator(stateDir string) (*NameAllocator, error) {
+	a := &NameAllocator{
+		file:      filepath.Join(stateDir, interfaceRegistryFile),
+		allocated: map[string]interfaceOwner{},
+	}
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *NameAllocator) load() error {
+	data, err := ioutil.ReadFile(a.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error reading interface name registry %s: %v", a.file, err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, &a.allocated)
+}
+
+// save writes the registry to a temporary file in the same directory and renames it over
+// the registry file, so a crash mid-write cannot leave a truncated file behind for load to
+// trip over on the next agent restart.
+func (a *NameAllocator) save() error {
+	data, err := json.Marshal(a.allocated)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(a.file), "."+filepath.Base(a.file)+".tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary interface name registry: %v", err)
+	}
+	tmpName := tmpFile.Name()
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("error writing temporary interface name registry: %v", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("error closing temporary interface name registry: %v", err)
+	}
+	if err := os.Rename(tmpName, a.file); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("error renaming temporary interface name registry into place: %v", err)
+	}
+	return nil
+}
+
+// Allocate generates a collision-safe interface name for the given Pod and persists the
+// allocation. If the Pod already owns a name from a previous call (including one reloaded
+// from disk across an agent restart), that name is returned unchanged rather than being
+// re-derived, so a freed collision salt can never be handed to the wrong Pod. Otherwise, if
+// the generated name is already owned by a different Pod, it retries with successive salts
+// appended to the hashed input until it finds a free name; if none of the first
+// maxNameAllocProbes candidates is free, it returns an error.
+func (a *NameAllocator) Allocate(podName, podNamespace, containerID string) (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for name, owner := range a.allocated {
+		if owner.PodName != podName || owner.PodNamespace != podNamespace {
+			continue
+		}
+		if owner.ContainerID != containerID {
+			a.allocated[name] = interfaceOwner{PodNamespace: podNamespace, PodName: podName, ContainerID: containerID}
+			if err := a.save(); err != nil {
+				return "", err
+			}
+		}
+		return name, nil
+	}
+
+	id := fmt.Sprintf("pod/%s/%s", podNamespace, podName)
+	for salt := 0; salt < maxNameAllocProbes; salt++ {
+		probeID := id
+		if salt > 0 {
+			probeID = fmt.Sprintf("%s/%d", id, salt)
+		}
+		name := generateInterfaceName(probeID, podName)
+		if _, ok := a.allocated[name]; ok {
+			interfaceNameCollisions.Inc()
+			continue
+		}
+		a.allocated[name] = interfaceOwner{PodNamespace: podNamespace, PodName: podName, ContainerID: containerID}
+		if err := a.save(); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("could not allocate an interface name for Pod %s/%s after %d attempts", podNamespace, podName, maxNameAllocProbes)
+}
+
+// Release removes name from the registry so it can be reused by a future Pod.
+func (a *NameAllocator) Release(name string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	delete(a.allocated, name)
+	return a.save()
+}
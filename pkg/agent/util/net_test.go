@@ -0,0 +1,106 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNameAllocatorAllocateSurvivesRestartAfterCollision reproduces the scenario Allocate's
+// up-front ownership scan exists for: Pod B's name is salted because Pod A already holds B's
+// first-choice name, A is later released freeing that name, and the agent restarts. Allocate
+// must still return B's previously persisted (salted) name instead of re-deriving and handing
+// out A's now-free name, which would silently rename B's interface and orphan the old registry
+// entry.
+func TestNameAllocatorAllocateSurvivesRestartAfterCollision(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewNameAllocator(dir)
+	require.NoError(t, err)
+
+	// Seed the registry with a fake owner on Pod B's unsalted name, simulating Pod A
+	// already holding it, so Allocate(B) is forced to probe past salt 0.
+	podBFirstChoice := generateInterfaceName("pod/default/podB", "podB")
+	a.allocated[podBFirstChoice] = interfaceOwner{PodNamespace: "default", PodName: "podA", ContainerID: "containerA"}
+	require.NoError(t, a.save())
+
+	podBName, err := a.Allocate("podB", "default", "containerB")
+	require.NoError(t, err)
+	assert.NotEqual(t, podBFirstChoice, podBName, "Pod B should have been salted past Pod A's name")
+
+	// Pod A is released, freeing its name.
+	require.NoError(t, a.Release(podBFirstChoice))
+
+	// Simulate an agent restart: a fresh allocator reloads the registry from disk.
+	restarted, err := NewNameAllocator(dir)
+	require.NoError(t, err)
+
+	name, err := restarted.Allocate("podB", "default", "containerB")
+	require.NoError(t, err)
+	assert.Equal(t, podBName, name, "Allocate must return Pod B's existing persisted name, not re-derive the now-free name")
+}
+
+func TestNameAllocatorAllocateUpdatesContainerID(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewNameAllocator(dir)
+	require.NoError(t, err)
+
+	name, err := a.Allocate("pod", "default", "container1")
+	require.NoError(t, err)
+
+	name2, err := a.Allocate("pod", "default", "container2")
+	require.NoError(t, err)
+	assert.Equal(t, name, name2)
+
+	b, err := NewNameAllocator(dir)
+	require.NoError(t, err)
+	owner := b.allocated[name]
+	assert.Equal(t, "container2", owner.ContainerID)
+}
+
+func TestNameAllocatorReleaseFreesName(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewNameAllocator(dir)
+	require.NoError(t, err)
+
+	name, err := a.Allocate("pod", "default", "container1")
+	require.NoError(t, err)
+	require.NoError(t, a.Release(name))
+
+	_, ok := a.allocated[name]
+	assert.False(t, ok)
+
+	b, err := NewNameAllocator(dir)
+	require.NoError(t, err)
+	_, ok = b.allocated[name]
+	assert.False(t, ok, "released name must not survive a reload from disk")
+}
+
+func TestNameAllocatorAllocateUniqueNamePerPod(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewNameAllocator(dir)
+	require.NoError(t, err)
+
+	names := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		name, err := a.Allocate(fmt.Sprintf("pod-%d", i), "default", fmt.Sprintf("container-%d", i))
+		require.NoError(t, err)
+		assert.False(t, names[name], "expected a unique name per Pod")
+		names[name] = true
+	}
+}
@@ -39,6 +39,9 @@ type RequestOption struct {
 	Kubeconfig string
 	Name       string
 	Args       map[string]string
+	// Watch upgrades the request to a chunked/streaming response so the caller receives
+	// objects as they change, similar to "kubectl get -w".
+	Watch bool
 }
 
 // URI returns the request path of the request option.
@@ -98,7 +101,9 @@ func (c *Client) resolveKubeconfig(opt *RequestOption) (*rest.Config, error) {
 	return kubeconfig, nil
 }
 
-// Do makes the request by the command and the request option.
+// Do makes the request by the command and the request option. When opt.Watch is set, the
+// returned io.Reader streams newline-delimited objects as they change instead of a single
+// complete response.
 func (c *Client) Do(cmd *cobra.Command, opt *RequestOption) (io.Reader, error) {
 	kubeconfig, err := c.resolveKubeconfig(opt)
 	if err != nil {
@@ -115,7 +120,16 @@ func (c *Client) Do(cmd *cobra.Command, opt *RequestOption) (io.Reader, error) {
 
 	uri := opt.URI(kubeconfig).String()
 	klog.Infof("Requesting URI %s", uri)
-	result := restClient.Get().RequestURI(uri).Do()
+	req := restClient.Get().RequestURI(uri)
+	if opt.Watch {
+		req = req.SetHeader("Accept", "application/json;stream=watch")
+		stream, err := req.Stream()
+		if err != nil {
+			return nil, fmt.Errorf("Error when starting watch on URI %s: %w", uri, err)
+		}
+		return stream, nil
+	}
+	result := req.Do()
 	if result.Error() != nil {
 		return nil, fmt.Errorf("Error when requesting URI %s: %w", uri, result.Error())
 	}
@@ -128,6 +142,7 @@ func (c *Client) Do(cmd *cobra.Command, opt *RequestOption) (io.Reader, error) {
 
 // Init initializes the root command.
 func Init(root *cobra.Command) {
+	root.PersistentFlags().StringP("output", "o", "table", "output format; one of table|json|yaml|jsonpath=<template>")
 	client := new(Client)
 	Definition.ApplyToClient(client)
 	Definition.ApplyToRootCommand(root, client, isAgent)
@@ -0,0 +1,159 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package antctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// CommandDefinition declares a single antctl command: the server-side resource it queries,
+// the columns printed by the default "table" output format, and whether it supports
+// --watch.
+type CommandDefinition struct {
+	// Name is both the resource name requested from the server and the subcommand name,
+	// e.g. "agentinfo" for "antctl get agentinfo".
+	Name  string
+	Short string
+	Long  string
+	// Agent and Controller select which antctl binary exposes this command; a command
+	// can be available from both.
+	Agent      bool
+	Controller bool
+	// TableColumns are the columns printed by the default "table" output format.
+	TableColumns []TableColumn
+	// Watchable enables the -w/--watch flag for this command.
+	Watchable bool
+}
+
+// commandDefinitions lists every antctl command. ApplyToRootCommand only registers, for the
+// running component, the commands whose Agent/Controller flag matches.
+var commandDefinitions = []CommandDefinition{
+	{
+		Name:  "agentinfo",
+		Short: "Print the information of the antrea-agent",
+		Long:  "Print the basic information of the antrea-agent, including its version, Node subnet, OpenFlow version, and the like.",
+		Agent: true,
+		TableColumns: []TableColumn{
+			{Header: "Version", JSONPath: "{.version}"},
+			{Header: "NodeName", JSONPath: "{.nodeName}"},
+		},
+		Watchable: true,
+	},
+	{
+		Name:       "controllerinfo",
+		Short:      "Print the information of the antrea-controller",
+		Long:       "Print the basic information of the antrea-controller, including its version and connected agent count.",
+		Controller: true,
+		TableColumns: []TableColumn{
+			{Header: "Version", JSONPath: "{.version}"},
+		},
+		Watchable: true,
+	},
+}
+
+// definition bundles the set of CommandDefinitions that Init wires onto the root command.
+type definition struct {
+	commands []CommandDefinition
+}
+
+// Definition is the set of commands antctl exposes.
+var Definition = &definition{commands: commandDefinitions}
+
+// ApplyToClient is a hook point for the definition to customize the Client used to issue
+// requests. No customization is required today.
+func (d *definition) ApplyToClient(c *Client) {}
+
+// ApplyToRootCommand registers, under a "get" subcommand of root, one cobra command per
+// CommandDefinition applicable to this component (agent or controller). Each command reads
+// the -o/--output flag registered on root and, if Watchable, its own -w/--watch flag, and
+// uses them to format the response from Client.Do.
+func (d *definition) ApplyToRootCommand(root *cobra.Command, client *Client, agent bool) {
+	getCmd := &cobra.Command{
+		Use:   "get",
+		Short: "Get the status or resource of a topic",
+	}
+	for _, def := range d.commands {
+		def := def
+		if agent && !def.Agent {
+			continue
+		}
+		if !agent && !def.Controller {
+			continue
+		}
+		cmd := &cobra.Command{
+			Use:   def.Name,
+			Short: def.Short,
+			Long:  def.Long,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runGetCommand(cmd, client, def)
+			},
+		}
+		if def.Watchable {
+			cmd.Flags().BoolP("watch", "w", false, "after listing the requested object, watch for changes and print them as they happen")
+		}
+		getCmd.AddCommand(cmd)
+	}
+	root.AddCommand(getCmd)
+}
+
+// runGetCommand issues the request declared by def, resolves the Formatter requested
+// through -o/--output, and renders the response (or, with --watch, each object in the
+// response stream) through it.
+func runGetCommand(cmd *cobra.Command, client *Client, def CommandDefinition) error {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	formatter, err := NewFormatter(output, def.TableColumns)
+	if err != nil {
+		return err
+	}
+
+	var watch bool
+	if def.Watchable {
+		watch, _ = cmd.Flags().GetBool("watch")
+	}
+
+	reader, err := client.Do(cmd, &RequestOption{Name: def.Name, Watch: watch})
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(reader)
+	if !watch {
+		var obj interface{}
+		if err := dec.Decode(&obj); err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+		return formatter.Format(cmd.OutOrStdout(), obj)
+	}
+
+	for {
+		var obj interface{}
+		if err := dec.Decode(&obj); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("error decoding watch stream: %w", err)
+		}
+		if err := formatter.Format(cmd.OutOrStdout(), obj); err != nil {
+			return err
+		}
+	}
+}
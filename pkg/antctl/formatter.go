@@ -0,0 +1,142 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package antctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// TableColumn declares one column of the "table" output format: Header is the column
+// title and JSONPath selects the field to print, e.g. "{.status.phase}".
+type TableColumn struct {
+	Header   string
+	JSONPath string
+}
+
+// Formatter renders a decoded response object, in whatever shape the server returned it, to
+// w in a specific output format.
+type Formatter interface {
+	Format(w io.Writer, obj interface{}) error
+}
+
+// NewFormatter resolves the Formatter requested through the -o/--output flag. output is
+// "table" (the default), "json", "yaml", or "jsonpath=<expr>". columns is used by the table
+// formatter and is ignored by the others.
+func NewFormatter(output string, columns []TableColumn) (Formatter, error) {
+	switch {
+	case output == "" || output == "table":
+		return &tableFormatter{columns: columns}, nil
+	case output == "json":
+		return jsonFormatter{}, nil
+	case output == "yaml":
+		return yamlFormatter{}, nil
+	case strings.HasPrefix(output, "jsonpath="):
+		return newJSONPathFormatter(strings.TrimPrefix(output, "jsonpath="))
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", output)
+	}
+}
+
+// tableFormatter renders obj as a kubectl-style column table.
+type tableFormatter struct {
+	columns []TableColumn
+}
+
+func (f *tableFormatter) Format(w io.Writer, obj interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	headers := make([]string, len(f.columns))
+	for i, c := range f.columns {
+		headers[i] = strings.ToUpper(c.Header)
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+	rows, err := toRows(obj)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		cells := make([]string, len(f.columns))
+		for i, c := range f.columns {
+			jp, err := newJSONPathFormatter(c.JSONPath)
+			if err != nil {
+				return err
+			}
+			var buf strings.Builder
+			if err := jp.Format(&buf, row); err != nil {
+				return err
+			}
+			cells[i] = buf.String()
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// toRows normalizes obj into the list of items a table has one row per: a JSON array
+// becomes its elements, anything else becomes a single row.
+func toRows(obj interface{}) ([]interface{}, error) {
+	if items, ok := obj.([]interface{}); ok {
+		return items, nil
+	}
+	return []interface{}{obj}, nil
+}
+
+// jsonFormatter renders obj as indented JSON.
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(w io.Writer, obj interface{}) error {
+	data, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// yamlFormatter renders obj as YAML.
+type yamlFormatter struct{}
+
+func (yamlFormatter) Format(w io.Writer, obj interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// jsonPathFormatter renders the result of evaluating a JSONPath template against obj.
+type jsonPathFormatter struct {
+	template *jsonpath.JSONPath
+}
+
+func newJSONPathFormatter(expr string) (*jsonPathFormatter, error) {
+	jp := jsonpath.New("antctl")
+	if err := jp.Parse(expr); err != nil {
+		return nil, fmt.Errorf("invalid jsonpath expression %q: %w", expr, err)
+	}
+	return &jsonPathFormatter{template: jp}, nil
+}
+
+func (f *jsonPathFormatter) Format(w io.Writer, obj interface{}) error {
+	return f.template.Execute(w, obj)
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package antctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// WatchAcceptHeader is the Accept header value Client.Do sends to request a streaming
+// response for a watchable command; a server handler should check for it to decide whether
+// to respond with a StreamWriter instead of a single encoded object.
+const WatchAcceptHeader = "application/json;stream=watch"
+
+// StreamWriter is the server-side half of the watch contract: it writes objects to a ResponseWriter
+// as newline-delimited JSON, flushing after each one, so Client.Do's chunked reader and the
+// decode loop in runGetCommand observe each object as soon as it is written rather than
+// buffered until the response completes.
+type StreamWriter interface {
+	// Write encodes obj as a single line of the stream and flushes it to the client.
+	Write(obj interface{}) error
+}
+
+// httpStreamWriter is the StreamWriter implementation backed by an http.ResponseWriter.
+type httpStreamWriter struct {
+	w   http.ResponseWriter
+	enc *json.Encoder
+}
+
+// NewStreamWriter returns a StreamWriter that writes to w, setting the headers a watch
+// response requires. It returns an error if w does not support flushing, since without it
+// the client would never see anything until the handler returns.
+func NewStreamWriter(w http.ResponseWriter) (StreamWriter, error) {
+	if _, ok := w.(http.Flusher); !ok {
+		return nil, fmt.Errorf("ResponseWriter does not support flushing, cannot stream")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+	return &httpStreamWriter{w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (s *httpStreamWriter) Write(obj interface{}) error {
+	if err := s.enc.Encode(obj); err != nil {
+		return err
+	}
+	s.w.(http.Flusher).Flush()
+	return nil
+}
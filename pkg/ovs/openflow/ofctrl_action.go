@@ -3,9 +3,12 @@ package openflow
 import (
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 
 	"github.com/contiv/libOpenflow/openflow13"
 	"github.com/contiv/ofnet/ofctrl"
+	"k8s.io/klog"
 )
 
 type ofFlowAction struct {
@@ -82,6 +85,10 @@ type ofCTAction struct {
 	ctBase
 	actions []openflow13.Action
 	builder *ofFlowBuilder
+	// err records the first error encountered while building this CT action (e.g. an
+	// unparsable SNAT range), so it can be surfaced once CTDone is called instead of
+	// silently installing a commit with the NAT action missing.
+	err error
 }
 
 // LoadToMark is an action to load data into ct_mark.
@@ -116,12 +123,284 @@ func (a *ofCTAction) move(fromField *openflow13.MatchField, toField *openflow13.
 	a.actions = append(a.actions, action)
 }
 
-// CTDone sets the conntrack action in the Openflow rule and it returns FlowBuilder.
+// NATFlags is a bitmask of the flags that can be passed to NATRange to control
+// the behavior of the NX_ACTION_NAT action.
+type NATFlags uint16
+
+const (
+	// NATFlagSNAT requests source NAT.
+	NATFlagSNAT NATFlags = 1 << iota
+	// NATFlagDNAT requests destination NAT.
+	NATFlagDNAT
+	// NATFlagPersistent requests that the translation survive datapath restarts.
+	NATFlagPersistent
+	// NATFlagRandom requests that the translated address/port be chosen at random
+	// rather than deterministically.
+	NATFlagRandom
+)
+
+// DNAT is an action to perform destination NAT to the specified IP and port with conntrack.
+func (a *ofCTAction) DNAT(ip net.IP, port uint16) CTAction {
+	return a.NATRange(ip, ip, port, port, NATFlagDNAT)
+}
+
+// SNAT is an action to perform source NAT to an address chosen from ipRange and, if
+// provided, a port chosen from portRange, with conntrack. ipRange and portRange use the
+// "min-max" notation accepted by OVS, e.g. "10.10.10.10-10.10.10.20" and "1024-65535".
+// A single address/port can be given without the "-max" part.
+func (a *ofCTAction) SNAT(ipRange string, portRange string) CTAction {
+	min, max, err := parseIPRange(ipRange)
+	if err != nil {
+		a.err = fmt.Errorf("invalid SNAT IP range %q: %v", ipRange, err)
+		return a
+	}
+	minPort, maxPort, err := parsePortRange(portRange)
+	if err != nil {
+		a.err = fmt.Errorf("invalid SNAT port range %q: %v", portRange, err)
+		return a
+	}
+	return a.NATRange(min, max, minPort, maxPort, NATFlagSNAT)
+}
+
+// NATRange is an action to perform NAT with conntrack, translating the packet's address
+// into the range [min, max] and, if non-zero, its port into the range [minPort, maxPort].
+// flags selects whether SNAT or DNAT is performed, and any additional NAT behavior. If max
+// is nil, it defaults to min (a single address rather than a range).
+func (a *ofCTAction) NATRange(min, max net.IP, minPort, maxPort uint16, flags NATFlags) CTAction {
+	if min != nil && max == nil {
+		max = min
+	}
+	action := openflow13.NewNXActionCTNAT()
+	if flags&NATFlagSNAT != 0 {
+		action.SetSNAT()
+	}
+	if flags&NATFlagDNAT != 0 {
+		action.SetDNAT()
+	}
+	if flags&NATFlagPersistent != 0 {
+		action.SetPersistent()
+	}
+	if flags&NATFlagRandom != 0 {
+		action.SetRandom()
+	}
+	if min != nil {
+		if min.To4() != nil {
+			action.SetRangeIPv4Min(min)
+			action.SetRangeIPv4Max(max)
+		} else {
+			action.SetRangeIPv6Min(min)
+			action.SetRangeIPv6Max(max)
+		}
+	}
+	if minPort > 0 {
+		action.SetRangePortMin(minPort)
+		action.SetRangePortMax(maxPort)
+	}
+	a.actions = append(a.actions, action)
+	return a
+}
+
+func parseIPRange(ipRange string) (net.IP, net.IP, error) {
+	addrs := strings.SplitN(ipRange, "-", 2)
+	min := net.ParseIP(addrs[0])
+	if min == nil {
+		return nil, nil, fmt.Errorf("invalid IP address %s", addrs[0])
+	}
+	max := min
+	if len(addrs) == 2 {
+		max = net.ParseIP(addrs[1])
+		if max == nil {
+			return nil, nil, fmt.Errorf("invalid IP address %s", addrs[1])
+		}
+	}
+	return min, max, nil
+}
+
+func parsePortRange(portRange string) (uint16, uint16, error) {
+	if portRange == "" {
+		return 0, 0, nil
+	}
+	ports := strings.SplitN(portRange, "-", 2)
+	min, err := strconv.ParseUint(ports[0], 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port %s", ports[0])
+	}
+	max := min
+	if len(ports) == 2 {
+		max, err = strconv.ParseUint(ports[1], 10, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid port %s", ports[1])
+		}
+	}
+	return uint16(min), uint16(max), nil
+}
+
+// CTDone sets the conntrack action in the Openflow rule and it returns FlowBuilder. If an
+// earlier call on this CTAction failed (e.g. SNAT was given an unparsable range), the CT
+// action is not installed at all: a commit missing the NAT action it was supposed to carry
+// would silently send untranslated traffic into conntrack, which is worse than dropping the
+// flow, so the error is logged and the builder is returned unmodified instead.
 func (a *ofCTAction) CTDone() FlowBuilder {
+	if a.err != nil {
+		klog.Errorf("Error building CT action, not installing it: %v", a.err)
+		return a.builder
+	}
 	a.builder.Flow.ConnTrack(a.commit, a.force, &a.ctTable, &a.ctZone, a.actions...)
 	return a.builder
 }
 
+// LearnField identifies a bit range of a packet header field or pipeline register used
+// as the source or destination of a LearnSpec.
+type LearnField struct {
+	Name  string
+	Range Range
+}
+
+// LearnSpec describes a single flow_mod_spec of a Learn action: it either contributes a
+// match criterion, a load action, or (when Output is true) an output action to the flow
+// that Learn installs. The source is SrcField when set, or the immediate value SrcValue
+// otherwise.
+type LearnSpec struct {
+	SrcField *LearnField
+	SrcValue uint64
+	DstField *LearnField
+	Match    bool
+	Output   bool
+}
+
+// Learn is an action to add a NX_ACTION_LEARN action to the flow. When the flow is hit,
+// OVS installs a new flow, built from the current packet according to specs, into
+// tableID with the given priority and cookie. This lets the datapath install
+// reverse-direction microflows for connection tracking of stateless protocols.
+func (a *ofFlowAction) Learn(tableID TableIDType, priority uint16, cookie uint64, specs ...LearnSpec) FlowBuilder {
+	learnAction := openflow13.NewNXActionLearn()
+	learnAction.TableId = uint8(tableID)
+	learnAction.Priority = priority
+	learnAction.Cookie = cookie
+	for _, spec := range specs {
+		switch {
+		case spec.Output:
+			// Output needs a source field to read the target port from; an immediate
+			// value makes no sense here.
+			if spec.SrcField == nil {
+				klog.Errorf("Skipping invalid learn spec: Output requires SrcField")
+				continue
+			}
+			learnAction.AddOutputFlowField(spec.SrcField.Name, int(spec.SrcField.Range[0]), int(spec.SrcField.Range[1]))
+		case spec.SrcField != nil:
+			if spec.DstField == nil {
+				klog.Errorf("Skipping invalid learn spec: field-sourced spec requires DstField")
+				continue
+			}
+			learnAction.AddMatchFlowField(spec.SrcField.Name, int(spec.SrcField.Range[0]), int(spec.SrcField.Range[1]), spec.DstField.Name, int(spec.DstField.Range[0]), int(spec.DstField.Range[1]), spec.Match)
+		default:
+			if spec.DstField == nil {
+				klog.Errorf("Skipping invalid learn spec: immediate-value spec requires DstField")
+				continue
+			}
+			learnAction.AddLoadAction(spec.SrcValue, spec.DstField.Name, int(spec.DstField.Range[0]), int(spec.DstField.Range[1]))
+		}
+	}
+	// Learn is a side-effecting action, not a terminal one: a flow must still reach a
+	// forwarding action (Output, Resubmit, ...) afterwards, so it is appended to the
+	// accumulated action list rather than overwriting lastAction.
+	a.builder.ofFlow.actions = append(a.builder.ofFlow.actions, learnAction)
+	return a.builder
+}
+
+// GroupType is an OpenFlow group type: select (load-balance across live buckets by
+// weight), all (execute every bucket), ff (fast failover: execute the first live
+// bucket), or indirect (always execute the single bucket).
+type GroupType string
+
+const (
+	GroupSelect   GroupType = "select"
+	GroupAll      GroupType = "all"
+	GroupFF       GroupType = "ff"
+	GroupIndirect GroupType = "indirect"
+)
+
+// Bucket is one weighted action list of a group.
+type Bucket struct {
+	Weight  uint16
+	Actions []openflow13.Action
+}
+
+// GroupBuilder builds an OpenFlow group made of weighted buckets, for example to
+// load-balance a Service's traffic across its Endpoints.
+type GroupBuilder interface {
+	// Bucket adds a bucket with the given weight (ignored for "all"/"ff"/"indirect"
+	// groups) and action list to the group.
+	Bucket(weight uint16, actions ...openflow13.Action) GroupBuilder
+	// Done realizes the group on the OFSwitch the builder was created from and returns
+	// it, ready to be referenced from a flow's Group action.
+	Done() (*ofGroup, error)
+}
+
+// ofGroup is the GroupBuilder implementation. It realizes its buckets into an
+// ofctrl.Group and installs it on sw once Done is called.
+type ofGroup struct {
+	sw        *ofctrl.OFSwitch
+	id        uint32
+	groupType GroupType
+	buckets   []Bucket
+}
+
+// NewGroupBuilder returns a GroupBuilder for a group with the given ID and type, to be
+// installed on sw.
+func NewGroupBuilder(sw *ofctrl.OFSwitch, id uint32, groupType GroupType) GroupBuilder {
+	return &ofGroup{sw: sw, id: id, groupType: groupType}
+}
+
+func (g *ofGroup) Bucket(weight uint16, actions ...openflow13.Action) GroupBuilder {
+	g.buckets = append(g.buckets, Bucket{Weight: weight, Actions: actions})
+	return g
+}
+
+func (g *ofGroup) Done() (*ofGroup, error) {
+	group, err := ofctrl.NewGroup(g.id, g.sw, ofGroupTypeToOfctrl(g.groupType))
+	if err != nil {
+		return nil, fmt.Errorf("error creating group %d: %w", g.id, err)
+	}
+	for _, bucket := range g.buckets {
+		group.AddBuckets(ofctrl.NewBucket(bucket.Weight, bucket.Actions))
+	}
+	if err := group.Install(); err != nil {
+		return nil, fmt.Errorf("error installing group %d: %w", g.id, err)
+	}
+	return g, nil
+}
+
+// ofGroupTypeToOfctrl maps our GroupType to the group type string used by ofctrl.Group.
+func ofGroupTypeToOfctrl(t GroupType) string {
+	switch t {
+	case GroupSelect, GroupAll, GroupFF:
+		return string(t)
+	default:
+		return string(GroupIndirect)
+	}
+}
+
+// Group is an action to output packets to the specified OpenFlow group. The group must
+// already have been realized on the switch via GroupBuilder.Done before a flow referencing
+// it is installed. It is appended to the accumulated action list rather than overwriting
+// lastAction, so it can be combined with a preceding Meter.
+func (a *ofFlowAction) Group(groupID uint32) FlowBuilder {
+	groupAction := ofctrl.NewGroupAction(groupID)
+	a.builder.ofFlow.actions = append(a.builder.ofFlow.actions, groupAction)
+	return a.builder
+}
+
+// Meter is an action to attach a meter to the flow for per-flow rate limiting. This backs
+// future NetworkPolicy bandwidth/QoS enforcement. It is appended to the accumulated action
+// list rather than overwriting lastAction, so a flow can still be metered and then forwarded
+// (e.g. via Output) by the same flow entry.
+func (a *ofFlowAction) Meter(meterID uint32) FlowBuilder {
+	meterAction := ofctrl.NewMeterAction(meterID)
+	a.builder.ofFlow.actions = append(a.builder.ofFlow.actions, meterAction)
+	return a.builder
+}
+
 // SetDstMAC is an action to modify packet destination MAC address to the specified address.
 func (a *ofFlowAction) SetDstMAC(addr net.HardwareAddr) FlowBuilder {
 	a.builder.SetMacDa(addr)
@@ -158,21 +437,57 @@ func (a *ofFlowAction) SetARPTpa(addr net.IP) FlowBuilder {
 	return a.builder
 }
 
-// SetSrcIP is an action to modify packet source IP address to the specified address.
+// SetSrcIP is an action to modify packet source IP address to the specified address. It
+// dispatches to the IPv6 field when addr is an IPv6 address.
 func (a *ofFlowAction) SetSrcIP(addr net.IP) FlowBuilder {
-	a.builder.SetIPField(addr, "Src")
+	if addr.To4() == nil {
+		a.builder.SetIPv6Field(addr, "Src")
+	} else {
+		a.builder.SetIPField(addr, "Src")
+	}
 	return a.builder
 }
 
 // SetDstIP is an action to modify packet destination IP address to the specified address.
+// It dispatches to the IPv6 field when addr is an IPv6 address.
 func (a *ofFlowAction) SetDstIP(addr net.IP) FlowBuilder {
-	a.builder.SetIPField(addr, "Dst")
+	if addr.To4() == nil {
+		a.builder.SetIPv6Field(addr, "Dst")
+	} else {
+		a.builder.SetIPField(addr, "Dst")
+	}
 	return a.builder
 }
 
-// SetTunnelDst is an action to modify packet tunnel destination address to the specified address.
+// SetTunnelDst is an action to modify packet tunnel destination address to the specified
+// address. It dispatches to the IPv6 tunnel field when addr is an IPv6 address.
 func (a *ofFlowAction) SetTunnelDst(addr net.IP) FlowBuilder {
-	a.builder.SetIPField(addr, "TunDst")
+	if addr.To4() == nil {
+		a.builder.SetIPv6Field(addr, "TunDst")
+	} else {
+		a.builder.SetIPField(addr, "TunDst")
+	}
+	return a.builder
+}
+
+// SetNDTarget is an action to modify a Neighbor Discovery packet's target address to the
+// specified address, analogous to SetARPTpa for ARP.
+func (a *ofFlowAction) SetNDTarget(addr net.IP) FlowBuilder {
+	a.builder.SetNDTarget(addr)
+	return a.builder
+}
+
+// SetNDSll is an action to modify a Neighbor Discovery packet's source link-layer address,
+// analogous to SetARPSha for ARP.
+func (a *ofFlowAction) SetNDSll(addr net.HardwareAddr) FlowBuilder {
+	a.builder.SetNDSll(addr)
+	return a.builder
+}
+
+// SetNDTll is an action to modify a Neighbor Discovery packet's target link-layer address,
+// analogous to SetARPTha for ARP.
+func (a *ofFlowAction) SetNDTll(addr net.HardwareAddr) FlowBuilder {
+	a.builder.SetNDTll(addr)
 	return a.builder
 }
 
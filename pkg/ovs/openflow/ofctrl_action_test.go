@@ -0,0 +1,101 @@
+package openflow
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIPRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantMin net.IP
+		wantMax net.IP
+		wantErr bool
+	}{
+		{
+			name:    "single address",
+			input:   "10.10.10.10",
+			wantMin: net.ParseIP("10.10.10.10"),
+			wantMax: net.ParseIP("10.10.10.10"),
+		},
+		{
+			name:    "range",
+			input:   "10.10.10.10-10.10.10.20",
+			wantMin: net.ParseIP("10.10.10.10"),
+			wantMax: net.ParseIP("10.10.10.20"),
+		},
+		{
+			name:    "invalid min",
+			input:   "not-an-ip-10.10.10.20",
+			wantErr: true,
+		},
+		{
+			name:    "invalid max",
+			input:   "10.10.10.10-not-an-ip",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			min, max, err := parseIPRange(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.wantMin.Equal(min))
+			assert.True(t, tt.wantMax.Equal(max))
+		})
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantMin uint16
+		wantMax uint16
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "single port",
+			input:   "1024",
+			wantMin: 1024,
+			wantMax: 1024,
+		},
+		{
+			name:    "range",
+			input:   "1024-65535",
+			wantMin: 1024,
+			wantMax: 65535,
+		},
+		{
+			name:    "invalid min",
+			input:   "abc-65535",
+			wantErr: true,
+		},
+		{
+			name:    "invalid max",
+			input:   "1024-abc",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			min, max, err := parsePortRange(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantMin, min)
+			assert.Equal(t, tt.wantMax, max)
+		})
+	}
+}
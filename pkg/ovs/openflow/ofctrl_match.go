@@ -0,0 +1,130 @@
+package openflow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/contiv/libOpenflow/openflow13"
+	"k8s.io/klog"
+)
+
+// NXM/OXM field names for the IPv6 and Neighbor Discovery fields this file adds set-field
+// support for, alongside the NxmFieldReg/NxmFieldCtMark names this package already
+// defines.
+const (
+	NxmFieldIPv6Src    = "OXM_OF_IPV6_SRC"
+	NxmFieldIPv6Dst    = "OXM_OF_IPV6_DST"
+	NxmFieldTunIPv6Dst = "NXM_NX_TUN_IPV6_DST"
+	NxmFieldNDTarget   = "NXM_NX_ND_TARGET"
+	NxmFieldNDSll      = "NXM_NX_ND_SLL"
+	NxmFieldNDTll      = "NXM_NX_ND_TLL"
+)
+
+// MatchSrcIPv6 adds a match condition for matching IPv6 packets with the specified source
+// IP address (OXM_OF_IPV6_SRC), analogous to the IPv4 MatchSrcIP.
+func (b *ofFlowBuilder) MatchSrcIPv6(ip net.IP) FlowBuilder {
+	b.Flow.MatchIPv6Src(ip)
+	return b
+}
+
+// MatchDstIPv6 adds a match condition for matching IPv6 packets with the specified
+// destination IP address (OXM_OF_IPV6_DST), analogous to the IPv4 MatchDstIP.
+func (b *ofFlowBuilder) MatchDstIPv6(ip net.IP) FlowBuilder {
+	b.Flow.MatchIPv6Dst(ip)
+	return b
+}
+
+// MatchICMPv6Type adds a match condition for matching ICMPv6 packets of the specified
+// type, e.g. Neighbor Solicitation (135) or Neighbor Advertisement (136).
+func (b *ofFlowBuilder) MatchICMPv6Type(icmpType uint8) FlowBuilder {
+	b.Flow.MatchIcmp6Type(icmpType)
+	return b
+}
+
+// SetIPv6Field is an action to modify the given packet field ("Src", "Dst", or "TunDst")
+// to the specified IPv6 address, analogous to SetIPField for IPv4. Like the 128-bit
+// ct_label field handled by LoadToLabelRange, the address is too wide for a single
+// register load and is written as two 64-bit loads covering its upper and lower halves.
+func (b *ofFlowBuilder) SetIPv6Field(addr net.IP, field string) FlowBuilder {
+	var fieldName string
+	switch field {
+	case "Src":
+		fieldName = NxmFieldIPv6Src
+	case "Dst":
+		fieldName = NxmFieldIPv6Dst
+	case "TunDst":
+		fieldName = NxmFieldTunIPv6Dst
+	default:
+		klog.Errorf("Unknown IPv6 field %q, not setting it", field)
+		return b
+	}
+	if err := b.loadIPv6(fieldName, addr); err != nil {
+		klog.Errorf("Error setting IPv6 field %q: %v", field, err)
+	}
+	return b
+}
+
+// SetNDTarget is an action to modify a Neighbor Discovery packet's target address
+// (ipv6_nd_target) to the specified address, analogous to SetARPTpa for ARP.
+func (b *ofFlowBuilder) SetNDTarget(addr net.IP) FlowBuilder {
+	if err := b.loadIPv6(NxmFieldNDTarget, addr); err != nil {
+		klog.Errorf("Error setting Neighbor Discovery target: %v", err)
+	}
+	return b
+}
+
+// SetNDSll is an action to modify a Neighbor Discovery packet's source link-layer address
+// (ipv6_nd_sll), analogous to SetARPSha for ARP.
+func (b *ofFlowBuilder) SetNDSll(mac net.HardwareAddr) FlowBuilder {
+	if err := b.loadMAC(NxmFieldNDSll, mac); err != nil {
+		klog.Errorf("Error setting Neighbor Discovery source link-layer address: %v", err)
+	}
+	return b
+}
+
+// SetNDTll is an action to modify a Neighbor Discovery packet's target link-layer address
+// (ipv6_nd_tll), analogous to SetARPTha for ARP.
+func (b *ofFlowBuilder) SetNDTll(mac net.HardwareAddr) FlowBuilder {
+	if err := b.loadMAC(NxmFieldNDTll, mac); err != nil {
+		klog.Errorf("Error setting Neighbor Discovery target link-layer address: %v", err)
+	}
+	return b
+}
+
+// loadIPv6 emits the pair of 64-bit register loads that together write a 128-bit IPv6
+// address field named fieldName. It returns an error, rather than building a nil-field
+// action that would panic at flow realization, when fieldName is unknown to libOpenflow.
+func (b *ofFlowBuilder) loadIPv6(fieldName string, addr net.IP) error {
+	field, err := openflow13.FindFieldHeaderByName(fieldName, false)
+	if err != nil {
+		return fmt.Errorf("unknown NXM/OXM field %q: %v", fieldName, err)
+	}
+	ip := addr.To16()
+	hi := binary.BigEndian.Uint64(ip[0:8])
+	lo := binary.BigEndian.Uint64(ip[8:16])
+	loRange := Range{0, 63}
+	hiRange := Range{64, 127}
+	b.ofFlow.actions = append(b.ofFlow.actions,
+		openflow13.NewNXActionRegLoad(loRange.ToNXRange().ToOfsBits(), field, lo),
+		openflow13.NewNXActionRegLoad(hiRange.ToNXRange().ToOfsBits(), field, hi),
+	)
+	return nil
+}
+
+// loadMAC emits the register load that writes a 48-bit hardware address field named
+// fieldName. It returns an error, rather than building a nil-field action that would panic
+// at flow realization, when fieldName is unknown to libOpenflow.
+func (b *ofFlowBuilder) loadMAC(fieldName string, mac net.HardwareAddr) error {
+	field, err := openflow13.FindFieldHeaderByName(fieldName, false)
+	if err != nil {
+		return fmt.Errorf("unknown NXM/OXM field %q: %v", fieldName, err)
+	}
+	var value uint64
+	for _, octet := range mac {
+		value = value<<8 | uint64(octet)
+	}
+	rng := Range{0, 47}
+	b.ofFlow.actions = append(b.ofFlow.actions, openflow13.NewNXActionRegLoad(rng.ToNXRange().ToOfsBits(), field, value))
+	return nil
+}